@@ -0,0 +1,194 @@
+/*
+ * Copyright (c) 2020 Devtron Labs
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package gitops
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+const githubApiBaseUrl = "https://api.github.com"
+
+var githubHttpClient = &http.Client{Timeout: 15 * time.Second}
+
+// githubGitClient is the first real gitClient implementation - it backs validateGitAuthentication
+// et al for ProviderGitHub using GitHub's REST API directly rather than a vendored SDK, since the
+// validation stages only ever need a handful of endpoints.
+type githubGitClient struct {
+	host  string
+	token string
+}
+
+func newGitHubClient(config *GitOpsConfigDto) gitClient {
+	token := config.Token
+	if config.AuthMode == AuthModeOAuth {
+		token = config.AccessToken
+	}
+	host := githubApiBaseUrl
+	if config.Host != "" && config.Host != "https://github.com" {
+		// GitHub Enterprise Server exposes the same REST API under /api/v3 on the instance host.
+		host = fmt.Sprintf("%s/api/v3", config.Host)
+	}
+	return &githubGitClient{host: host, token: token}
+}
+
+func (c *githubGitClient) Authenticate() error {
+	_, err := c.do(http.MethodGet, "/user", nil)
+	return err
+}
+
+func (c *githubGitClient) CheckOrgRepoCreatePermission(orgId string) error {
+	body, err := c.do(http.MethodGet, fmt.Sprintf("/orgs/%s", orgId), nil)
+	if err != nil {
+		return err
+	}
+	var org struct {
+		MembersCanCreateRepos bool `json:"members_can_create_repositories"`
+	}
+	if err := json.Unmarshal(body, &org); err != nil {
+		return fmt.Errorf("unexpected response from github org lookup: %w", err)
+	}
+	if !org.MembersCanCreateRepos {
+		// members_can_create_repositories only reflects the org-wide default; an org owner
+		// can still create repos even when it is false, so this is a soft signal rather than
+		// a hard failure - the authoritative check is CreateRepo itself failing later.
+		return nil
+	}
+	return nil
+}
+
+func (c *githubGitClient) CreateTestRepo(name string) (string, error) {
+	payload := map[string]interface{}{
+		"name":        name,
+		"private":     true,
+		"description": "devtron gitops validation test repo, safe to delete",
+		"auto_init":   true,
+	}
+	body, err := c.do(http.MethodPost, "/user/repos", payload)
+	if err != nil {
+		return "", err
+	}
+	var repo struct {
+		CloneUrl string `json:"clone_url"`
+	}
+	if err := json.Unmarshal(body, &repo); err != nil {
+		return "", fmt.Errorf("unexpected response from github repo creation: %w", err)
+	}
+	return repo.CloneUrl, nil
+}
+
+// PushTestCommit writes a file through the Contents API instead of a real git push - it
+// exercises the same write permission a git push would need without shelling out to git or
+// vendoring a git library just for this one validation stage.
+func (c *githubGitClient) PushTestCommit(repoUrl string) error {
+	owner, repo, err := parseGitHubOwnerRepo(repoUrl)
+	if err != nil {
+		return err
+	}
+	payload := map[string]interface{}{
+		"message": "devtron gitops validation test commit",
+		"content": "ZGV2dHJvbiBnaXRvcHMgdmFsaWRhdGlvbg==", // "devtron gitops validation"
+	}
+	_, err = c.do(http.MethodPut, fmt.Sprintf("/repos/%s/%s/contents/devtron-validation.txt", owner, repo), payload)
+	return err
+}
+
+func (c *githubGitClient) DeleteTestRepo(name string) error {
+	owner, err := c.authenticatedLogin()
+	if err != nil {
+		return err
+	}
+	_, err = c.do(http.MethodDelete, fmt.Sprintf("/repos/%s/%s", owner, name), nil)
+	return err
+}
+
+func (c *githubGitClient) authenticatedLogin() (string, error) {
+	body, err := c.do(http.MethodGet, "/user", nil)
+	if err != nil {
+		return "", err
+	}
+	var user struct {
+		Login string `json:"login"`
+	}
+	if err := json.Unmarshal(body, &user); err != nil {
+		return "", fmt.Errorf("unexpected response from github user lookup: %w", err)
+	}
+	return user.Login, nil
+}
+
+func (c *githubGitClient) do(method, path string, payload interface{}) ([]byte, error) {
+	var bodyReader io.Reader
+	if payload != nil {
+		b, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		bodyReader = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, c.host+path, bodyReader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := githubHttpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("github request %s %s failed: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("github request %s %s returned %d: %s", method, path, resp.StatusCode, string(respBody))
+	}
+	return respBody, nil
+}
+
+// parseGitHubOwnerRepo extracts "owner", "repo" from a clone URL of the form
+// https://github.com/owner/repo.git.
+func parseGitHubOwnerRepo(cloneUrl string) (owner string, repo string, err error) {
+	const prefix = "https://github.com/"
+	if len(cloneUrl) <= len(prefix) || cloneUrl[:len(prefix)] != prefix {
+		return "", "", fmt.Errorf("unrecognized github clone url %q", cloneUrl)
+	}
+	rest := cloneUrl[len(prefix):]
+	for i := 0; i < len(rest); i++ {
+		if rest[i] == '/' {
+			owner = rest[:i]
+			repo = rest[i+1:]
+			break
+		}
+	}
+	if owner == "" || repo == "" {
+		return "", "", fmt.Errorf("unrecognized github clone url %q", cloneUrl)
+	}
+	if len(repo) > 4 && repo[len(repo)-4:] == ".git" {
+		repo = repo[:len(repo)-4]
+	}
+	return owner, repo, nil
+}
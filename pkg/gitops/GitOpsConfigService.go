@@ -18,6 +18,7 @@
 package gitops
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/devtron-labs/devtron/client/argocdServer"
@@ -30,32 +31,104 @@ import (
 	"github.com/ghodss/yaml"
 	"go.uber.org/zap"
 	"k8s.io/apimachinery/pkg/api/errors"
-	"net/http"
+	"math/rand"
 	"time"
 )
 
 type GitOpsConfigService interface {
-	CreateGitOpsConfig(config *GitOpsConfigDto) (*GitOpsConfigDto, error)
-	UpdateGitOpsConfig(config *GitOpsConfigDto) error
-	GetGitOpsConfigById(id int) (*GitOpsConfigDto, error)
-	GetAllGitOpsConfig() ([]*GitOpsConfigDto, error)
-	GetGitOpsConfigByProvider(provider string) (*GitOpsConfigDto, error)
+	CreateGitOpsConfig(ctx context.Context, config *GitOpsConfigDto) (*GitOpsConfigDto, error)
+	UpdateGitOpsConfig(ctx context.Context, config *GitOpsConfigDto) error
+	GetGitOpsConfigById(ctx context.Context, id int) (*GitOpsConfigDto, error)
+	GetAllGitOpsConfig(ctx context.Context) ([]*GitOpsConfigDto, error)
+	GetGitOpsConfigByProvider(ctx context.Context, provider string) (*GitOpsConfigDto, error)
+	AssignGitOpsConfigToEnv(ctx context.Context, configId int, envId int) error
+	GetActiveGitOpsConfigForEnv(ctx context.Context, envId int) (*GitOpsConfigDto, error)
 }
 
+// GitOpsK8sTimeout is the single deadline CreateGitOpsConfig/UpdateGitOpsConfig set at the start
+// of the call, before pre-persist validation runs. All of their K8s apiserver round-trips
+// (GetSecretFast, CreateSecretFast, GetConfigMapFast, UpdateConfigMapFast) share what is left of
+// it. It applies on top of whatever deadline the caller's ctx already carries, whichever is sooner.
+const GitOpsK8sTimeout = 15 * time.Second
+
+const configMapRetryLimit = 3
+const configMapRetryBaseDelay = 200 * time.Millisecond
+
+// configMapRetryBackoff returns the delay before retry number attempt, doubling each time
+// off configMapRetryBaseDelay and adding up to 50% jitter so concurrent callers racing on
+// the same ConfigMap's resourceVersion don't retry in lockstep.
+func configMapRetryBackoff(attempt int) time.Duration {
+	backoff := configMapRetryBaseDelay * time.Duration(1<<uint(attempt-1))
+	jitter := time.Duration(rand.Int63n(int64(backoff) / 2))
+	return backoff + jitter
+}
+
+// AuthMode determines the shape of the credential that is written to the
+// devtron-gitops-secret Secret and, in turn, to the ArgoCD repository.credentials block.
+type AuthMode string
+
+const (
+	AuthModeToken AuthMode = "token"
+	AuthModeOAuth AuthMode = "oauth"
+	AuthModeSSH   AuthMode = "ssh"
+)
+
 type GitOpsConfigDto struct {
-	Id            int    `json:"id,omitempty"`
-	Provider      string `json:"provider"`
-	Username      string `json:"username"`
-	Token         string `json:"token"`
-	GitLabGroupId string `json:"gitLabGroupId"`
-	GitHubOrgId   string `json:"gitHubOrgId"`
-	Host          string `json:"host"`
-	Active        bool   `json:"active"`
+	Id int `json:"id,omitempty"`
+	// Name uniquely identifies this config so multiple configs can coexist for the same
+	// Provider (e.g. two GitHub orgs, or GitHub Enterprise + github.com). It also derives
+	// the per-config Secret name, so configs sharing a Host don't clobber each other's
+	// repository.credentials entry.
+	Name string `json:"name"`
+	// Scope is an operator-facing label (e.g. "platform-team", "prod-only") with no
+	// behavioral effect of its own - AssignGitOpsConfigToEnv is what actually binds a
+	// config to an environment.
+	Scope    string `json:"scope,omitempty"`
+	Provider string `json:"provider"`
+	Username string `json:"username"`
+	Token    string `json:"token"`
+	Host     string `json:"host"`
+	Active   bool   `json:"active"`
+	// ProviderConfig carries the provider-specific fields the GitProvider registry needs
+	// (e.g. {"org": "..."} for github, {"workspace": "..."} for bitbucket_cloud).
+	ProviderConfig map[string]string `json:"providerConfig,omitempty"`
+	AuthMode       AuthMode          `json:"authMode,omitempty"`
+	// OAuth fields, only populated/relevant when AuthMode == AuthModeOAuth.
+	// ClientId/ClientSecret are the registered OAuth app credentials for the provider;
+	// AccessToken/RefreshToken/TokenExpiry are the result of the completed authorization-code flow.
+	OAuthClientId     string    `json:"oAuthClientId,omitempty"`
+	OAuthClientSecret string    `json:"-"`
+	AccessToken       string    `json:"-"`
+	RefreshToken      string    `json:"-"`
+	TokenExpiry       time.Time `json:"tokenExpiry,omitempty"`
+	// SkipValidation bypasses ValidateGitOpsConfig's live "test and apply" checks. Intended
+	// only for operators who need to force-save a config that the live checks can't reach.
+	SkipValidation bool `json:"skipValidation,omitempty"`
+	// SecretBackend/SecretRef, when SecretBackend names an external backend (anything other
+	// than "" or "k8s"), mean the plaintext Token above is not persisted - Devtron stores only
+	// the reference and resolves/rotates the K8s Secret via SyncSecrets.
+	SecretBackend string `json:"secretBackend,omitempty"`
+	SecretRef     string `json:"secretRef,omitempty"`
 	UserId        int32  `json:"-"`
 }
 
 const GitOpsSecretName = "devtron-gitops-secret"
 
+// secretNameForConfig derives the per-config Secret name so multiple GitOpsConfigs can
+// coexist without clobbering each other's credential - the unnamed/default config keeps
+// using the bare GitOpsSecretName for backwards compatibility with configs created before
+// Name existed.
+func secretNameForConfig(request *GitOpsConfigDto) string {
+	return secretNameForName(request.Name)
+}
+
+func secretNameForName(name string) string {
+	if name == "" {
+		return GitOpsSecretName
+	}
+	return fmt.Sprintf("%s-%s", GitOpsSecretName, name)
+}
+
 type GitOpsConfigServiceImpl struct {
 	logger           *zap.SugaredLogger
 	gitOpsRepository repository.GitOpsConfigRepository
@@ -64,6 +137,11 @@ type GitOpsConfigServiceImpl struct {
 	clusterService   cluster.ClusterService
 	envService       cluster.EnvironmentService
 	versionService   argocdServer.VersionService
+	oAuthState
+	// gitClientFactoryOverride lets tests substitute a fake gitClient without hitting any real
+	// provider API or SDK. Production code leaves this nil, so gitClientFactory falls back to
+	// newDefaultGitClient.
+	gitClientFactoryOverride func(config *GitOpsConfigDto) gitClient
 }
 
 func NewGitOpsConfigServiceImpl(Logger *zap.SugaredLogger, ciHandler pipeline.CiHandler,
@@ -79,19 +157,56 @@ func NewGitOpsConfigServiceImpl(Logger *zap.SugaredLogger, ciHandler pipeline.Ci
 		versionService:   versionService,
 	}
 }
-func (impl *GitOpsConfigServiceImpl) CreateGitOpsConfig(request *GitOpsConfigDto) (*GitOpsConfigDto, error) {
+func (impl *GitOpsConfigServiceImpl) CreateGitOpsConfig(ctx context.Context, request *GitOpsConfigDto) (*GitOpsConfigDto, error) {
 	impl.logger.Debugw("gitops create request", "req", request)
+	ctx, cancel := context.WithTimeout(ctx, GitOpsK8sTimeout)
+	defer cancel()
+	if !request.SkipValidation {
+		validationResult, err := impl.ValidateGitOpsConfig(request)
+		if err != nil {
+			return nil, err
+		}
+		if !validationResult.Successful {
+			return nil, &util.ApiError{
+				InternalMessage: fmt.Sprintf("gitops config failed pre-persist validation: %+v", validationResult.Stages),
+				UserMessage:     "gitops config failed validation, see stage results for details",
+			}
+		}
+	}
+	provider, err := defaultGitProviderRegistry.get(request.Provider)
+	if err != nil {
+		return nil, err
+	}
+	if err := provider.Validate(request); err != nil {
+		return nil, &util.ApiError{InternalMessage: err.Error(), UserMessage: err.Error()}
+	}
 	model := &repository.GitOpsConfig{
-		Provider:      request.Provider,
-		Username:      request.Username,
-		Token:         request.Token,
-		GitHubOrgId:   request.GitHubOrgId,
-		GitLabGroupId: request.GitLabGroupId,
-		Host:          request.Host,
-		Active:        request.Active,
-		AuditLog:      models.AuditLog{CreatedBy: request.UserId, CreatedOn: time.Now(), UpdatedOn: time.Now(), UpdatedBy: request.UserId},
-	}
-	model, err := impl.gitOpsRepository.CreateGitOpsConfig(model)
+		Name:           request.Name,
+		Scope:          request.Scope,
+		Provider:       request.Provider,
+		Username:       request.Username,
+		ProviderConfig: request.ProviderConfig,
+		Host:           request.Host,
+		Active:         request.Active,
+		AuthMode:       string(request.AuthMode),
+		SecretBackend:  request.SecretBackend,
+		SecretRef:      request.SecretRef,
+		AuditLog:       models.AuditLog{CreatedBy: request.UserId, CreatedOn: time.Now(), UpdatedOn: time.Now(), UpdatedBy: request.UserId},
+	}
+	// the plaintext token is only persisted in the DB when no external SecretBackend is in
+	// play; otherwise only the reference is stored, resolved below when the Secret is first
+	// written and again by SyncSecrets on each of its periodic passes.
+	if request.SecretBackend == "" || SecretBackendType(request.SecretBackend) == SecretBackendK8s {
+		model.Token = request.Token
+	}
+	if request.AuthMode == AuthModeOAuth {
+		model.OAuthClientId = request.OAuthClientId
+		model.OAuthClientSecret = request.OAuthClientSecret
+		model.AccessToken = request.AccessToken
+		model.RefreshToken = request.RefreshToken
+		model.TokenExpiry = request.TokenExpiry
+	}
+	model, err = impl.gitOpsRepository.CreateGitOpsConfig(model)
 	if err != nil {
 		impl.logger.Errorw("error in saving gitops config", "data", model, "err", err)
 		err = &util.ApiError{
@@ -115,14 +230,19 @@ func (impl *GitOpsConfigServiceImpl) CreateGitOpsConfig(request *GitOpsConfigDto
 		return nil, err
 	}
 
-	secret, err := impl.K8sUtil.GetSecretFast(impl.aCDAuthConfig.ACDConfigMapNamespace, GitOpsSecretName, client)
-	statusError, _ := err.(*errors.StatusError)
-	if err != nil && statusError.Status().Code != http.StatusNotFound {
+	secretName := secretNameForConfig(request)
+	secret, err := impl.K8sUtil.GetSecretFast(ctx, impl.aCDAuthConfig.ACDConfigMapNamespace, secretName, client)
+	if err != nil && !errors.IsNotFound(err) {
 		impl.logger.Errorw("secret not found", "err", err)
 		return nil, err
 	}
+	credential, err := impl.resolveSecretValue(request)
+	if err != nil {
+		impl.logger.Errorw("error resolving gitops credential", "err", err)
+		return nil, err
+	}
 	if secret == nil {
-		secret, err = impl.K8sUtil.CreateSecretFast(impl.aCDAuthConfig.ACDConfigMapNamespace, request.Username, request.Token, client)
+		secret, err = impl.K8sUtil.CreateSecretFast(ctx, impl.aCDAuthConfig.ACDConfigMapNamespace, secretName, request.Username, credential, client)
 		if err != nil {
 			impl.logger.Errorw("err", "err", err)
 			return nil, err
@@ -131,19 +251,27 @@ func (impl *GitOpsConfigServiceImpl) CreateGitOpsConfig(request *GitOpsConfigDto
 
 	operationComplete := false
 	retryCount := 0
-	for !operationComplete && retryCount < 3 {
+	for !operationComplete && retryCount < configMapRetryLimit {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if retryCount > 0 {
+			time.Sleep(configMapRetryBackoff(retryCount))
+		}
 		retryCount = retryCount + 1
 
-		cm, err := impl.K8sUtil.GetConfigMapFast(impl.aCDAuthConfig.ACDConfigMapNamespace, impl.aCDAuthConfig.ACDConfigMapName, client)
+		cm, err := impl.K8sUtil.GetConfigMapFast(ctx, impl.aCDAuthConfig.ACDConfigMapNamespace, impl.aCDAuthConfig.ACDConfigMapName, client)
 		if err != nil {
 			return nil, err
 		}
-		updatedData, existsInArgodCm := impl.updateData(cm.Data, request, GitOpsSecretName)
-		if ! existsInArgodCm {
+		updatedData, existsInArgodCm := impl.updateData(cm.Data, request, secretName)
+		updatedRepos, reposUnchanged := impl.updateRepositories(cm.Data, request, secretName)
+		if ! existsInArgodCm || !reposUnchanged {
 			data := cm.Data
 			data["repository.credentials"] = updatedData["repository.credentials"]
+			data["repositories"] = updatedRepos["repositories"]
 			cm.Data = data
-			_, err = impl.K8sUtil.UpdateConfigMapFast(impl.aCDAuthConfig.ACDConfigMapNamespace, cm, client)
+			_, err = impl.K8sUtil.UpdateConfigMapFast(ctx, impl.aCDAuthConfig.ACDConfigMapNamespace, cm, client)
 			if err != nil {
 				continue
 			}
@@ -155,14 +283,38 @@ func (impl *GitOpsConfigServiceImpl) CreateGitOpsConfig(request *GitOpsConfigDto
 		}
 	}
 	if !operationComplete {
-		return nil, fmt.Errorf("resouce version not matched with config map attemped 3 times")
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("resouce version not matched with config map attemped %d times", configMapRetryLimit)
 	}
 
 	request.Id = model.Id
 	return request, nil
 }
-func (impl *GitOpsConfigServiceImpl) UpdateGitOpsConfig(request *GitOpsConfigDto) error {
+func (impl *GitOpsConfigServiceImpl) UpdateGitOpsConfig(ctx context.Context, request *GitOpsConfigDto) error {
 	impl.logger.Debugw("gitops config update request", "req", request)
+	ctx, cancel := context.WithTimeout(ctx, GitOpsK8sTimeout)
+	defer cancel()
+	if !request.SkipValidation {
+		validationResult, err := impl.ValidateGitOpsConfig(request)
+		if err != nil {
+			return err
+		}
+		if !validationResult.Successful {
+			return &util.ApiError{
+				InternalMessage: fmt.Sprintf("gitops config failed pre-persist validation: %+v", validationResult.Stages),
+				UserMessage:     "gitops config failed validation, see stage results for details",
+			}
+		}
+	}
+	provider, err := defaultGitProviderRegistry.get(request.Provider)
+	if err != nil {
+		return err
+	}
+	if err := provider.Validate(request); err != nil {
+		return &util.ApiError{InternalMessage: err.Error(), UserMessage: err.Error()}
+	}
 	model, err := impl.gitOpsRepository.GetGitOpsConfigById(request.Id)
 	if err != nil {
 		impl.logger.Errorw("No matching entry found for update.", "id", request.Id)
@@ -172,13 +324,28 @@ func (impl *GitOpsConfigServiceImpl) UpdateGitOpsConfig(request *GitOpsConfigDto
 		}
 		return err
 	}
+	model.Name = request.Name
+	model.Scope = request.Scope
 	model.Provider = request.Provider
 	model.Username = request.Username
-	model.Token = request.Token
-	model.GitLabGroupId = request.GitLabGroupId
-	model.GitHubOrgId = request.GitHubOrgId
+	model.ProviderConfig = request.ProviderConfig
 	model.Host = request.Host
 	model.Active = request.Active
+	model.AuthMode = string(request.AuthMode)
+	model.SecretBackend = request.SecretBackend
+	model.SecretRef = request.SecretRef
+	if request.SecretBackend == "" || SecretBackendType(request.SecretBackend) == SecretBackendK8s {
+		model.Token = request.Token
+	} else {
+		model.Token = ""
+	}
+	if request.AuthMode == AuthModeOAuth {
+		model.OAuthClientId = request.OAuthClientId
+		model.OAuthClientSecret = request.OAuthClientSecret
+		model.AccessToken = request.AccessToken
+		model.RefreshToken = request.RefreshToken
+		model.TokenExpiry = request.TokenExpiry
+	}
 	err = impl.gitOpsRepository.UpdateGitOpsConfig(model)
 	if err != nil {
 		impl.logger.Errorw("error in updating team", "data", model, "err", err)
@@ -204,14 +371,19 @@ func (impl *GitOpsConfigServiceImpl) UpdateGitOpsConfig(request *GitOpsConfigDto
 		return err
 	}
 
-	secret, err := impl.K8sUtil.GetSecretFast(impl.aCDAuthConfig.ACDConfigMapNamespace, GitOpsSecretName, client)
-	statusError, _ := err.(*errors.StatusError)
-	if err != nil && statusError.Status().Code != http.StatusNotFound {
+	secretName := secretNameForConfig(request)
+	secret, err := impl.K8sUtil.GetSecretFast(ctx, impl.aCDAuthConfig.ACDConfigMapNamespace, secretName, client)
+	if err != nil && !errors.IsNotFound(err) {
 		impl.logger.Errorw("secret not found", "err", err)
 		return err
 	}
+	credential, err := impl.resolveSecretValue(request)
+	if err != nil {
+		impl.logger.Errorw("error resolving gitops credential", "err", err)
+		return err
+	}
 	if secret == nil {
-		secret, err = impl.K8sUtil.CreateSecretFast(impl.aCDAuthConfig.ACDConfigMapNamespace, request.Username, request.Token, client)
+		secret, err = impl.K8sUtil.CreateSecretFast(ctx, impl.aCDAuthConfig.ACDConfigMapNamespace, secretName, request.Username, credential, client)
 		if err != nil {
 			impl.logger.Errorw("err", "err", err)
 			return err
@@ -220,19 +392,27 @@ func (impl *GitOpsConfigServiceImpl) UpdateGitOpsConfig(request *GitOpsConfigDto
 
 	operationComplete := false
 	retryCount := 0
-	for !operationComplete && retryCount < 3 {
+	for !operationComplete && retryCount < configMapRetryLimit {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if retryCount > 0 {
+			time.Sleep(configMapRetryBackoff(retryCount))
+		}
 		retryCount = retryCount + 1
 
-		cm, err := impl.K8sUtil.GetConfigMapFast(impl.aCDAuthConfig.ACDConfigMapNamespace, impl.aCDAuthConfig.ACDConfigMapName, client)
+		cm, err := impl.K8sUtil.GetConfigMapFast(ctx, impl.aCDAuthConfig.ACDConfigMapNamespace, impl.aCDAuthConfig.ACDConfigMapName, client)
 		if err != nil {
 			return err
 		}
-		updatedData, existsInArgodCm := impl.updateData(cm.Data, request, GitOpsSecretName)
-		if ! existsInArgodCm {
+		updatedData, existsInArgodCm := impl.updateData(cm.Data, request, secretName)
+		updatedRepos, reposUnchanged := impl.updateRepositories(cm.Data, request, secretName)
+		if ! existsInArgodCm || !reposUnchanged {
 			data := cm.Data
 			data["repository.credentials"] = updatedData["repository.credentials"]
+			data["repositories"] = updatedRepos["repositories"]
 			cm.Data = data
-			_, err = impl.K8sUtil.UpdateConfigMapFast(impl.aCDAuthConfig.ACDConfigMapNamespace, cm, client)
+			_, err = impl.K8sUtil.UpdateConfigMapFast(ctx, impl.aCDAuthConfig.ACDConfigMapNamespace, cm, client)
 			if err != nil {
 				continue
 			}
@@ -244,31 +424,25 @@ func (impl *GitOpsConfigServiceImpl) UpdateGitOpsConfig(request *GitOpsConfigDto
 		}
 	}
 	if !operationComplete {
-		return fmt.Errorf("resouce version not matched with config map attemped 3 times")
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("resouce version not matched with config map attemped %d times", configMapRetryLimit)
 	}
 
 	return nil
 }
 
-func (impl *GitOpsConfigServiceImpl) GetGitOpsConfigById(id int) (*GitOpsConfigDto, error) {
+func (impl *GitOpsConfigServiceImpl) GetGitOpsConfigById(ctx context.Context, id int) (*GitOpsConfigDto, error) {
 	model, err := impl.gitOpsRepository.GetGitOpsConfigById(id)
 	if err != nil {
 		impl.logger.Errorw("GetGitOpsConfigById, error while get by id", "err", err, "id", id)
 		return nil, err
 	}
-	config := &GitOpsConfigDto{
-		Id:            model.Id,
-		Provider:      model.Provider,
-		GitHubOrgId:   model.GitHubOrgId,
-		GitLabGroupId: model.GitLabGroupId,
-		Active:        model.Active,
-		UserId:        model.CreatedBy,
-	}
-
-	return config, err
+	return gitOpsConfigModelToDto(model), nil
 }
 
-func (impl *GitOpsConfigServiceImpl) GetAllGitOpsConfig() ([]*GitOpsConfigDto, error) {
+func (impl *GitOpsConfigServiceImpl) GetAllGitOpsConfig(ctx context.Context) ([]*GitOpsConfigDto, error) {
 	models, err := impl.gitOpsRepository.GetAllGitOpsConfig()
 	if err != nil {
 		impl.logger.Errorw("GetAllGitOpsConfig, error while fetch all", "err", err)
@@ -276,37 +450,53 @@ func (impl *GitOpsConfigServiceImpl) GetAllGitOpsConfig() ([]*GitOpsConfigDto, e
 	}
 	var configs []*GitOpsConfigDto
 	for _, model := range models {
-		config := &GitOpsConfigDto{
-			Id:            model.Id,
-			Provider:      model.Provider,
-			GitHubOrgId:   model.GitHubOrgId,
-			GitLabGroupId: model.GitLabGroupId,
-			Active:        model.Active,
-			UserId:        model.CreatedBy,
-		}
-		configs = append(configs, config)
+		configs = append(configs, gitOpsConfigModelToDto(model))
 	}
-	return configs, err
+	return configs, nil
 }
 
-func (impl *GitOpsConfigServiceImpl) GetGitOpsConfigByProvider(provider string) (*GitOpsConfigDto, error) {
+func (impl *GitOpsConfigServiceImpl) GetGitOpsConfigByProvider(ctx context.Context, provider string) (*GitOpsConfigDto, error) {
 	model, err := impl.gitOpsRepository.GetGitOpsConfigByProvider(provider)
 	if err != nil {
 		impl.logger.Errorw("GetGitOpsConfigByProvider, error while get by name", "err", err, "provider", provider)
 		return nil, err
 	}
-	config := &GitOpsConfigDto{
-		Id:            model.Id,
-		Provider:      model.Provider,
-		GitHubOrgId:   model.GitHubOrgId,
-		GitLabGroupId: model.GitLabGroupId,
-		Active:        model.Active,
-		UserId:        model.CreatedBy,
-	}
+	return gitOpsConfigModelToDto(model), nil
+}
 
-	return config, err
+// gitOpsConfigModelToDto converts a persisted repository.GitOpsConfig into the DTO shape
+// callers actually resolve a config for - Host/Username/Token/AuthMode and the OAuth/secret
+// fields, not just the identifying ones. GetActiveGitOpsConfigForEnv (and any other caller
+// resolving a config to actually push a chart) depends on this returning something it can
+// connect with, not just an id and a name.
+func gitOpsConfigModelToDto(model *repository.GitOpsConfig) *GitOpsConfigDto {
+	return &GitOpsConfigDto{
+		Id:                model.Id,
+		Name:              model.Name,
+		Scope:             model.Scope,
+		Provider:          model.Provider,
+		Username:          model.Username,
+		Token:             model.Token,
+		Host:              model.Host,
+		Active:            model.Active,
+		ProviderConfig:    model.ProviderConfig,
+		AuthMode:          AuthMode(model.AuthMode),
+		OAuthClientId:     model.OAuthClientId,
+		OAuthClientSecret: model.OAuthClientSecret,
+		AccessToken:       model.AccessToken,
+		RefreshToken:      model.RefreshToken,
+		TokenExpiry:       model.TokenExpiry,
+		SecretBackend:     model.SecretBackend,
+		SecretRef:         model.SecretRef,
+		UserId:            model.CreatedBy,
+	}
 }
 
+// updateData appends or refreshes request's entry in the repository.credentials ConfigMap
+// block. It keys on (Host, secretName) rather than Host alone, since multiple GitOpsConfigs
+// can now share the same Host (e.g. two orgs on github.com) - each gets its own Secret, so
+// matching on Host alone would make a second config overwrite the first's entry instead of
+// adding its own.
 func (impl *GitOpsConfigServiceImpl) updateData(data map[string]string, request *GitOpsConfigDto, secretName string) (map[string]string, bool) {
 	found := false
 	var repositories []*RepositoryCredentialsDto
@@ -322,7 +512,7 @@ func (impl *GitOpsConfigServiceImpl) updateData(data map[string]string, request
 		}
 	}
 	for _, item := range repositories {
-		if item.Url == request.Host {
+		if item.Url == request.Host && item.UsernameSecret != nil && item.UsernameSecret.Name == secretName {
 			usernameSecret := &KeyDto{Name: secretName, Key: "username"}
 			passwordSecret := &KeyDto{Name: secretName, Key: "password"}
 			item.PasswordSecret = passwordSecret
@@ -331,7 +521,10 @@ func (impl *GitOpsConfigServiceImpl) updateData(data map[string]string, request
 		}
 	}
 	if !found {
-		repoData := impl.createRepoElement(request)
+		repoData, err := impl.createRepoElement(request, secretName)
+		if err != nil {
+			panic(err)
+		}
 		repositories = append(repositories, repoData)
 	}
 	rb, err := json.Marshal(repositories)
@@ -349,14 +542,88 @@ func (impl *GitOpsConfigServiceImpl) updateData(data map[string]string, request
 	return repositoryCredentials, found
 }
 
-func (impl *GitOpsConfigServiceImpl) createRepoElement(request *GitOpsConfigDto) *RepositoryCredentialsDto {
-	repoData := &RepositoryCredentialsDto{}
-	usernameSecret := &KeyDto{Name: request.Username, Key: "username"}
-	passwordSecret := &KeyDto{Name: request.Token, Key: "password"}
-	repoData.PasswordSecret = passwordSecret
-	repoData.UsernameSecret = usernameSecret
-	repoData.Url = request.Host
-	return repoData
+// updateRepositories writes a narrower, org-scoped entry into the "repositories" ConfigMap
+// block alongside repository.credentials. repository.credentials matches by Host prefix alone,
+// so when two configs share a Host but differ in ProviderConfig (e.g. two GitHub orgs on
+// github.com), ArgoCD can't tell their repos apart from the credential template - an explicit
+// per-repo entry, keyed by (org-scoped URL, secretName), resolves the ambiguity.
+func (impl *GitOpsConfigServiceImpl) updateRepositories(data map[string]string, request *GitOpsConfigDto, secretName string) (map[string]string, bool) {
+	unchanged := false
+	var repositories []*RepositoryCredentialsDto
+	repoStr := data["repositories"]
+	if len(repoStr) > 0 {
+		repoByte, err := yaml.YAMLToJSON([]byte(repoStr))
+		if err != nil {
+			panic(err)
+		}
+		err = json.Unmarshal(repoByte, &repositories)
+		if err != nil {
+			panic(err)
+		}
+	}
+	scopedUrl := orgScopedUrl(request)
+	for _, item := range repositories {
+		if item.Url == scopedUrl && item.UsernameSecret != nil && item.UsernameSecret.Name == secretName {
+			unchanged = true
+		}
+	}
+	if !unchanged {
+		repositories = append(repositories, &RepositoryCredentialsDto{
+			Url:            scopedUrl,
+			UsernameSecret: &KeyDto{Name: secretName, Key: "username"},
+			PasswordSecret: &KeyDto{Name: secretName, Key: "password"},
+		})
+	}
+	rb, err := json.Marshal(repositories)
+	if err != nil {
+		panic(err)
+	}
+	repositoriesYamlByte, err := yaml.JSONToYAML(rb)
+	if err != nil {
+		panic(err)
+	}
+	result := map[string]string{}
+	if len(repositoriesYamlByte) > 0 {
+		result["repositories"] = string(repositoriesYamlByte)
+	}
+	return result, unchanged
+}
+
+// orgScopedUrl narrows Host down to the specific org/group/workspace this config manages, so
+// two configs sharing a Host produce distinct "repositories" entries. azure_devops needs both
+// organization and project to disambiguate - either alone can collide across configs (e.g. two
+// orgs reusing the same project name).
+func orgScopedUrl(cfg *GitOpsConfigDto) string {
+	if org := providerConfigString(cfg, "organization"); org != "" {
+		if project := providerConfigString(cfg, "project"); project != "" {
+			return fmt.Sprintf("%s/%s/%s", cfg.Host, org, project)
+		}
+	}
+	for _, key := range []string{"org", "group", "workspace", "project"} {
+		if v := providerConfigString(cfg, key); v != "" {
+			return fmt.Sprintf("%s/%s", cfg.Host, v)
+		}
+	}
+	return cfg.Host
+}
+
+func (impl *GitOpsConfigServiceImpl) createRepoElement(request *GitOpsConfigDto, secretName string) (*RepositoryCredentialsDto, error) {
+	provider, err := defaultGitProviderRegistry.get(request.Provider)
+	if err != nil {
+		return nil, err
+	}
+	return provider.BuildRepoCredential(request, secretName), nil
+}
+
+// credentialForSecret returns the value that should be written to the "password" key of
+// the devtron-gitops-secret Secret for the given config, accounting for AuthMode. OAuth
+// configs write the current access token rather than the static Token field, so a token
+// refreshed by the background reconciler is picked up without the caller knowing about OAuth.
+func (impl *GitOpsConfigServiceImpl) credentialForSecret(request *GitOpsConfigDto) string {
+	if request.AuthMode == AuthModeOAuth {
+		return request.AccessToken
+	}
+	return request.Token
 }
 
 type RepositoryCredentialsDto struct {
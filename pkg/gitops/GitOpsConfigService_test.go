@@ -0,0 +1,74 @@
+/*
+ * Copyright (c) 2020 Devtron Labs
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package gitops
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigMapRetryBackoff(t *testing.T) {
+	for attempt := 1; attempt <= configMapRetryLimit; attempt++ {
+		backoff := configMapRetryBackoff(attempt)
+		min := configMapRetryBaseDelay * time.Duration(1<<uint(attempt-1))
+		max := min + min/2
+		assert.GreaterOrEqual(t, backoff, min)
+		assert.LessOrEqual(t, backoff, max)
+	}
+}
+
+func TestSecretNameForName(t *testing.T) {
+	assert.Equal(t, GitOpsSecretName, secretNameForName(""))
+	assert.Equal(t, GitOpsSecretName+"-prod", secretNameForName("prod"))
+}
+
+func TestOrgScopedUrl(t *testing.T) {
+	cases := []struct {
+		name string
+		cfg  *GitOpsConfigDto
+		want string
+	}{
+		{
+			name: "organization and project both set",
+			cfg:  &GitOpsConfigDto{Host: "https://dev.azure.com", ProviderConfig: map[string]string{"organization": "acme", "project": "platform"}},
+			want: "https://dev.azure.com/acme/platform",
+		},
+		{
+			name: "org only",
+			cfg:  &GitOpsConfigDto{Host: "https://github.com", ProviderConfig: map[string]string{"org": "devtron-labs"}},
+			want: "https://github.com/devtron-labs",
+		},
+		{
+			name: "workspace only",
+			cfg:  &GitOpsConfigDto{Host: "https://bitbucket.org", ProviderConfig: map[string]string{"workspace": "team"}},
+			want: "https://bitbucket.org/team",
+		},
+		{
+			name: "no provider config",
+			cfg:  &GitOpsConfigDto{Host: "https://github.com"},
+			want: "https://github.com",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, orgScopedUrl(tc.cfg))
+		})
+	}
+}
@@ -0,0 +1,62 @@
+/*
+ * Copyright (c) 2020 Devtron Labs
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package gitops
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/devtron-labs/devtron/internal/util"
+)
+
+// AssignGitOpsConfigToEnv binds envId to configId in gitops_config_environment_mapping, so
+// GetActiveGitOpsConfigForEnv (and, through it, the chart-push/app-deploy code paths) resolve
+// the right GitOpsConfig instead of assuming a single global active config. Re-assigning an
+// env that already has a mapping replaces it - an env only ever has one active GitOps config.
+func (impl *GitOpsConfigServiceImpl) AssignGitOpsConfigToEnv(ctx context.Context, configId int, envId int) error {
+	if _, err := impl.gitOpsRepository.GetGitOpsConfigById(configId); err != nil {
+		impl.logger.Errorw("AssignGitOpsConfigToEnv, gitops config does not exist", "configId", configId, "err", err)
+		return &util.ApiError{
+			InternalMessage: "gitops config assignment failed, config does not exist",
+			UserMessage:     "gitops config assignment failed, config does not exist",
+		}
+	}
+	if err := impl.gitOpsRepository.CreateOrUpdateEnvironmentMapping(configId, envId); err != nil {
+		impl.logger.Errorw("error assigning gitops config to env", "configId", configId, "envId", envId, "err", err)
+		return &util.ApiError{
+			InternalMessage: "gitops config assignment failed to persist",
+			UserMessage:     "gitops config assignment failed to persist",
+		}
+	}
+	return nil
+}
+
+// GetActiveGitOpsConfigForEnv resolves the GitOpsConfig an env was assigned via
+// AssignGitOpsConfigToEnv. Callers that used to rely on a single global active config
+// (chart-push, app-deploy) should resolve per env/app through this instead.
+func (impl *GitOpsConfigServiceImpl) GetActiveGitOpsConfigForEnv(ctx context.Context, envId int) (*GitOpsConfigDto, error) {
+	configId, err := impl.gitOpsRepository.GetConfigIdForEnv(envId)
+	if err != nil {
+		impl.logger.Errorw("GetActiveGitOpsConfigForEnv, no gitops config assigned", "envId", envId, "err", err)
+		return nil, &util.ApiError{
+			InternalMessage: fmt.Sprintf("no gitops config assigned to env %d", envId),
+			UserMessage:     "no gitops config assigned to this environment",
+		}
+	}
+	return impl.GetGitOpsConfigById(ctx, configId)
+}
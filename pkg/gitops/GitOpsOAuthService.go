@@ -0,0 +1,337 @@
+/*
+ * Copyright (c) 2020 Devtron Labs
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package gitops
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/devtron-labs/devtron/internal/util"
+	"github.com/devtron-labs/devtron/pkg/cluster"
+)
+
+// oAuthEndpoints holds the authorize/token/refresh URLs for a provider's
+// authorization-code flow. GitHub, GitLab, Bitbucket Cloud and Azure DevOps are
+// listed; the GitHub and GitLab entries point at the public github.com/gitlab.com hosts.
+type oAuthEndpoints struct {
+	AuthorizeURL string
+	TokenURL     string
+	RefreshURL   string
+}
+
+var providerOAuthEndpoints = map[string]oAuthEndpoints{
+	"github": {
+		AuthorizeURL: "https://github.com/login/oauth/authorize",
+		TokenURL:     "https://github.com/login/oauth/access_token",
+		RefreshURL:   "https://github.com/login/oauth/access_token",
+	},
+	"gitlab": {
+		AuthorizeURL: "https://gitlab.com/oauth/authorize",
+		TokenURL:     "https://gitlab.com/oauth/token",
+		RefreshURL:   "https://gitlab.com/oauth/token",
+	},
+	"bitbucket_cloud": {
+		AuthorizeURL: "https://bitbucket.org/site/oauth2/authorize",
+		TokenURL:     "https://bitbucket.org/site/oauth2/access_token",
+		RefreshURL:   "https://bitbucket.org/site/oauth2/access_token",
+	},
+	"azure_devops": {
+		AuthorizeURL: "https://app.vssps.visualstudio.com/oauth2/authorize",
+		TokenURL:     "https://app.vssps.visualstudio.com/oauth2/token",
+		RefreshURL:   "https://app.vssps.visualstudio.com/oauth2/token",
+	},
+}
+
+// OAuthStartResponse is returned to the UI so it can redirect the user to the provider.
+type OAuthStartResponse struct {
+	AuthURL string `json:"authUrl"`
+	State   string `json:"state"`
+}
+
+// oAuthPendingRequest is the bit of state devtron needs to remember between StartOAuth
+// and CompleteOAuth, keyed by the opaque state token handed back by the provider.
+type oAuthPendingRequest struct {
+	Provider     string
+	RedirectURI  string
+	ClientId     string
+	ClientSecret string
+	CreatedOn    time.Time
+}
+
+// TokenRefreshInterval is how often the background reconciler checks for OAuth tokens
+// that are close to expiry.
+const TokenRefreshInterval = 5 * time.Minute
+
+// tokenRefreshSafetyWindow is how far ahead of expiry a token is proactively refreshed.
+const tokenRefreshSafetyWindow = 10 * time.Minute
+
+// oAuthPendingTTL bounds how long a StartOAuth state token stays usable. An abandoned
+// authorization (user closes the tab, provider redirect never lands) would otherwise sit in
+// oAuthPending forever; CompleteOAuth rejects anything older than this as expired, and
+// StartOAuth opportunistically sweeps expired entries out on every call.
+const oAuthPendingTTL = 10 * time.Minute
+
+func (impl *GitOpsConfigServiceImpl) StartOAuth(provider string, redirectURI string) (*OAuthStartResponse, error) {
+	endpoints, ok := providerOAuthEndpoints[provider]
+	if !ok {
+		return nil, &util.ApiError{
+			InternalMessage: fmt.Sprintf("oauth not supported for provider %s", provider),
+			UserMessage:     fmt.Sprintf("oauth not supported for provider %s", provider),
+		}
+	}
+	state, err := generateOAuthState()
+	if err != nil {
+		impl.logger.Errorw("error generating oauth state", "err", err)
+		return nil, err
+	}
+	clientId, err := impl.gitOpsRepository.GetOAuthClientId(provider)
+	if err != nil {
+		impl.logger.Errorw("error fetching oauth client config", "provider", provider, "err", err)
+		return nil, err
+	}
+	clientSecret, err := impl.gitOpsRepository.GetOAuthClientSecret(provider)
+	if err != nil {
+		impl.logger.Errorw("error fetching oauth client config", "provider", provider, "err", err)
+		return nil, err
+	}
+	impl.oAuthMutex.Lock()
+	if impl.oAuthPending == nil {
+		impl.oAuthPending = map[string]*oAuthPendingRequest{}
+	}
+	impl.pruneExpiredOAuthPendingLocked()
+	impl.oAuthPending[state] = &oAuthPendingRequest{Provider: provider, RedirectURI: redirectURI, ClientId: clientId, ClientSecret: clientSecret, CreatedOn: time.Now()}
+	impl.oAuthMutex.Unlock()
+
+	authURL := fmt.Sprintf("%s?client_id=%s&redirect_uri=%s&state=%s&response_type=code", endpoints.AuthorizeURL, clientId, redirectURI, state)
+	return &OAuthStartResponse{AuthURL: authURL, State: state}, nil
+}
+
+func (impl *GitOpsConfigServiceImpl) CompleteOAuth(code string, state string) (*GitOpsConfigDto, error) {
+	impl.oAuthMutex.Lock()
+	pending, ok := impl.oAuthPending[state]
+	if ok {
+		delete(impl.oAuthPending, state)
+	}
+	impl.oAuthMutex.Unlock()
+	if !ok || time.Since(pending.CreatedOn) > oAuthPendingTTL {
+		return nil, &util.ApiError{
+			HttpStatusCode:  http.StatusBadRequest,
+			InternalMessage: "unknown or expired oauth state",
+			UserMessage:     "oauth session expired, please retry",
+		}
+	}
+
+	endpoints := providerOAuthEndpoints[pending.Provider]
+	accessToken, refreshToken, expiresIn, err := impl.exchangeOAuthCode(endpoints.TokenURL, pending.ClientId, code, pending.RedirectURI)
+	if err != nil {
+		impl.logger.Errorw("error exchanging oauth code", "provider", pending.Provider, "err", err)
+		return nil, err
+	}
+
+	config := &GitOpsConfigDto{
+		Provider:          pending.Provider,
+		AuthMode:          AuthModeOAuth,
+		OAuthClientId:     pending.ClientId,
+		OAuthClientSecret: pending.ClientSecret,
+		AccessToken:       accessToken,
+		RefreshToken:      refreshToken,
+		TokenExpiry:       time.Now().Add(time.Duration(expiresIn) * time.Second),
+	}
+	return config, nil
+}
+
+// pruneExpiredOAuthPendingLocked drops oAuthPending entries older than oAuthPendingTTL.
+// Callers must hold oAuthMutex.
+func (impl *GitOpsConfigServiceImpl) pruneExpiredOAuthPendingLocked() {
+	now := time.Now()
+	for state, pending := range impl.oAuthPending {
+		if now.Sub(pending.CreatedOn) > oAuthPendingTTL {
+			delete(impl.oAuthPending, state)
+		}
+	}
+}
+
+// oAuthHTTPClient is shared by exchangeOAuthCode/refreshOAuthToken; both are short, infrequent
+// calls so there is no need for one client per request.
+var oAuthHTTPClient = &http.Client{Timeout: 15 * time.Second}
+
+// oAuthTokenResponse covers the fields every provider in providerOAuthEndpoints returns from
+// its token/refresh endpoint. ExpiresIn is seconds until expiry; a provider that omits it
+// (some refresh responses reuse the prior lifetime) leaves it zero, which the caller treats as
+// "needs a closer look next reconcile pass" rather than an error.
+type oAuthTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+	Error        string `json:"error"`
+	ErrorDesc    string `json:"error_description"`
+}
+
+// exchangeOAuthCode trades an authorization code for an access/refresh token pair. Every
+// provider in providerOAuthEndpoints speaks the standard RFC 6749 authorization_code grant,
+// so one implementation covers all of them.
+func (impl *GitOpsConfigServiceImpl) exchangeOAuthCode(tokenURL, clientId, code, redirectURI string) (accessToken string, refreshToken string, expiresIn int, err error) {
+	form := url.Values{
+		"grant_type":   {"authorization_code"},
+		"client_id":    {clientId},
+		"code":         {code},
+		"redirect_uri": {redirectURI},
+	}
+	return postOAuthForm(tokenURL, form)
+}
+
+// RefreshExpiringOAuthTokens is invoked periodically by the background reconciler. It looks
+// up every active OAuth-mode config whose token is within tokenRefreshSafetyWindow of expiry,
+// refreshes it against the provider, and rewrites the devtron-gitops-secret Secret in place so
+// ArgoCD picks up the new credential without a user ever touching the UI.
+func (impl *GitOpsConfigServiceImpl) RefreshExpiringOAuthTokens() {
+	configs, err := impl.gitOpsRepository.GetAllOAuthConfigsNearingExpiry(tokenRefreshSafetyWindow)
+	if err != nil {
+		impl.logger.Errorw("error fetching oauth configs nearing expiry", "err", err)
+		return
+	}
+	for _, model := range configs {
+		endpoints, ok := providerOAuthEndpoints[model.Provider]
+		if !ok {
+			continue
+		}
+		accessToken, refreshToken, expiresIn, err := impl.refreshOAuthToken(endpoints.RefreshURL, model.OAuthClientId, model.OAuthClientSecret, model.RefreshToken)
+		if err != nil {
+			impl.logger.Errorw("error refreshing oauth token", "provider", model.Provider, "configId", model.Id, "err", err)
+			continue
+		}
+		model.AccessToken = accessToken
+		model.RefreshToken = refreshToken
+		model.TokenExpiry = time.Now().Add(time.Duration(expiresIn) * time.Second)
+		if err := impl.gitOpsRepository.UpdateOAuthTokens(model); err != nil {
+			impl.logger.Errorw("error persisting refreshed oauth token", "configId", model.Id, "err", err)
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), GitOpsK8sTimeout)
+		err = impl.rewriteSecretWithToken(ctx, secretNameForName(model.Name), model.Username, accessToken)
+		cancel()
+		if err != nil {
+			impl.logger.Errorw("error updating gitops secret with refreshed token", "configId", model.Id, "err", err)
+		}
+	}
+}
+
+func (impl *GitOpsConfigServiceImpl) refreshOAuthToken(refreshURL, clientId, clientSecret, refreshToken string) (accessToken string, newRefreshToken string, expiresIn int, err error) {
+	form := url.Values{
+		"grant_type":    {"refresh_token"},
+		"client_id":     {clientId},
+		"client_secret": {clientSecret},
+		"refresh_token": {refreshToken},
+	}
+	return postOAuthForm(refreshURL, form)
+}
+
+// postOAuthForm POSTs a form-encoded OAuth2 request and parses the JSON token response.
+// "Accept: application/json" makes GitHub (whose default response is form-encoded) return
+// JSON like every other provider here, so the parsing stays provider-agnostic.
+func postOAuthForm(tokenURL string, form url.Values) (accessToken string, refreshToken string, expiresIn int, err error) {
+	req, err := http.NewRequest(http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", "", 0, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	resp, err := oAuthHTTPClient.Do(req)
+	if err != nil {
+		return "", "", 0, fmt.Errorf("oauth request to %s failed: %w", tokenURL, err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", "", 0, fmt.Errorf("oauth response from %s could not be read: %w", tokenURL, err)
+	}
+	var parsed oAuthTokenResponse
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return "", "", 0, fmt.Errorf("oauth response from %s was not valid JSON: %w", tokenURL, err)
+	}
+	if parsed.Error != "" {
+		return "", "", 0, fmt.Errorf("oauth request to %s rejected: %s (%s)", tokenURL, parsed.Error, parsed.ErrorDesc)
+	}
+	if parsed.AccessToken == "" {
+		return "", "", 0, fmt.Errorf("oauth response from %s did not include an access_token", tokenURL)
+	}
+	return parsed.AccessToken, parsed.RefreshToken, parsed.ExpiresIn, nil
+}
+
+// rewriteSecretWithToken overwrites the password key of a config's devtron-gitops-secret
+// Secret with a freshly refreshed token, reusing the same cluster/client lookup as
+// Create/UpdateGitOpsConfig. secretName is the per-config Secret name (secretNameForName).
+func (impl *GitOpsConfigServiceImpl) rewriteSecretWithToken(ctx context.Context, secretName, username, token string) error {
+	clusterBean, err := impl.clusterService.FindOne(cluster.ClusterName)
+	if err != nil {
+		return err
+	}
+	cfg, err := impl.envService.GetClusterConfig(clusterBean)
+	if err != nil {
+		return err
+	}
+	client, err := impl.K8sUtil.GetClient(cfg)
+	if err != nil {
+		return err
+	}
+	_, err = impl.K8sUtil.CreateSecretFast(ctx, impl.aCDAuthConfig.ACDConfigMapNamespace, secretName, username, token, client)
+	return err
+}
+
+// StartOAuthTokenRefresher launches the ticker-driven background reconciler. It should be
+// called once from the application wiring (the same place other background loops are started).
+func (impl *GitOpsConfigServiceImpl) StartOAuthTokenRefresher() {
+	ticker := time.NewTicker(TokenRefreshInterval)
+	go func() {
+		for range ticker.C {
+			impl.RefreshExpiringOAuthTokens()
+		}
+	}()
+}
+
+func generateOAuthState() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// oAuthState is embedded into GitOpsConfigServiceImpl via the sync.Mutex/map pair below;
+// declared here rather than in GitOpsConfigService.go since it is only ever touched by
+// the OAuth flow.
+//
+// oAuthPending is in-process memory, not shared across replicas. This assumes devtron runs
+// the gitops OAuth flow as a single replica: if StartOAuth is handled by one pod and the
+// provider's redirect back to CompleteOAuth lands on another, that pod's oAuthPending won't
+// have the state and the exchange will fail with "oauth session expired". Running this with
+// multiple replicas needs oAuthPending moved to shared storage (e.g. the same Secret/ConfigMap
+// the rest of this package already uses) before it will work correctly.
+type oAuthState struct {
+	oAuthMutex   sync.Mutex
+	oAuthPending map[string]*oAuthPendingRequest
+}
@@ -0,0 +1,93 @@
+/*
+ * Copyright (c) 2020 Devtron Labs
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package gitops
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// fakeGitClient lets tests drive validateGitAuthentication/validateOrgPermission/
+// validateRepoCreation down either the success or the failure path without a real provider.
+type fakeGitClient struct {
+	authErr           error
+	orgPermissionErr  error
+	createTestRepoUrl string
+	createTestRepoErr error
+	pushTestCommitErr error
+	deleteTestRepoErr error
+}
+
+func (f *fakeGitClient) Authenticate() error                                 { return f.authErr }
+func (f *fakeGitClient) CheckOrgRepoCreatePermission(orgId string) error     { return f.orgPermissionErr }
+func (f *fakeGitClient) CreateTestRepo(name string) (string, error) {
+	return f.createTestRepoUrl, f.createTestRepoErr
+}
+func (f *fakeGitClient) PushTestCommit(repoUrl string) error { return f.pushTestCommitErr }
+func (f *fakeGitClient) DeleteTestRepo(name string) error    { return f.deleteTestRepoErr }
+
+func newTestValidationImpl(client gitClient) *GitOpsConfigServiceImpl {
+	return &GitOpsConfigServiceImpl{
+		logger:                   zap.NewNop().Sugar(),
+		gitClientFactoryOverride: func(config *GitOpsConfigDto) gitClient { return client },
+	}
+}
+
+func TestValidateGitAuthentication(t *testing.T) {
+	impl := newTestValidationImpl(&fakeGitClient{})
+	result := impl.validateGitAuthentication(&GitOpsConfigDto{Provider: ProviderGitLab})
+	assert.True(t, result.Successful)
+
+	impl = newTestValidationImpl(&fakeGitClient{authErr: assert.AnError})
+	result = impl.validateGitAuthentication(&GitOpsConfigDto{Provider: ProviderGitLab})
+	assert.False(t, result.Successful)
+	assert.Equal(t, assert.AnError.Error(), result.ErrorMessage)
+
+	impl = newTestValidationImpl(&fakeGitClient{authErr: errClientNotImplemented})
+	result = impl.validateGitAuthentication(&GitOpsConfigDto{Provider: ProviderGitLab})
+	assert.True(t, result.Successful)
+}
+
+func TestValidateOrgPermission(t *testing.T) {
+	impl := newTestValidationImpl(&fakeGitClient{orgPermissionErr: assert.AnError})
+	result := impl.validateOrgPermission(&GitOpsConfigDto{Provider: ProviderGitHub, ProviderConfig: map[string]string{"org": "devtron-labs"}})
+	assert.False(t, result.Successful)
+
+	impl = newTestValidationImpl(&fakeGitClient{orgPermissionErr: errClientNotImplemented})
+	result = impl.validateOrgPermission(&GitOpsConfigDto{Provider: ProviderGitHub, ProviderConfig: map[string]string{"org": "devtron-labs"}})
+	assert.True(t, result.Successful)
+}
+
+func TestValidateRepoCreation(t *testing.T) {
+	impl := newTestValidationImpl(&fakeGitClient{createTestRepoUrl: "https://github.com/devtron-labs/devtron-gitops-validation-test.git"})
+	result := impl.validateRepoCreation(&GitOpsConfigDto{Provider: ProviderGitHub, ProviderConfig: map[string]string{"org": "devtron-labs"}})
+	assert.True(t, result.Successful)
+
+	impl = newTestValidationImpl(&fakeGitClient{createTestRepoErr: assert.AnError})
+	result = impl.validateRepoCreation(&GitOpsConfigDto{Provider: ProviderGitHub, ProviderConfig: map[string]string{"org": "devtron-labs"}})
+	assert.False(t, result.Successful)
+
+	// Provider without a real gitClient yet: the stage passes without calling anything else,
+	// and the stage result is marked Skipped since no real API call verified anything.
+	impl = newTestValidationImpl(&fakeGitClient{createTestRepoErr: errClientNotImplemented})
+	result = impl.validateRepoCreation(&GitOpsConfigDto{Provider: ProviderGitea, ProviderConfig: map[string]string{"org": "devtron-labs"}})
+	assert.True(t, result.Successful)
+	assert.True(t, result.Skipped)
+}
@@ -0,0 +1,222 @@
+/*
+ * Copyright (c) 2020 Devtron Labs
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package gitops
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// GitProvider abstracts everything about a Git hosting provider that GitOpsConfigService
+// needs: validating the provider-specific config, shaping the ArgoCD repository.credentials
+// entry, and building the clone URL of a repo. CreateRepo only formats that URL: it makes no
+// call to the provider's API and creates no repo, and ValidateGitOpsConfig does not use it.
+// Registering a new provider means adding an entry to providerSpecs.
+type GitProvider interface {
+	Name() string
+	// Validate checks that cfg.ProviderConfig carries everything this provider requires
+	// (e.g. a workspace or project key) before the config is persisted.
+	Validate(cfg *GitOpsConfigDto) error
+	// BuildRepoCredential shapes the ArgoCD repository.credentials entry for this provider.
+	BuildRepoCredential(cfg *GitOpsConfigDto, secretName string) *RepositoryCredentialsDto
+	CreateRepo(ctx context.Context, cfg *GitOpsConfigDto, name string, description string) (url string, err error)
+}
+
+// GitProviderSchema describes a provider's ProviderConfig shape so the UI can render a
+// provider-specific form without devtron hard-coding field names on the frontend.
+type GitProviderSchema struct {
+	Provider       string            `json:"provider"`
+	RequiredFields []string          `json:"requiredFields"`
+	FieldPatterns  map[string]string `json:"fieldPatterns,omitempty"`
+}
+
+const (
+	ProviderGitHub          = "github"
+	ProviderGitLab          = "gitlab"
+	ProviderBitbucketCloud  = "bitbucket_cloud"
+	ProviderBitbucketServer = "bitbucket_server"
+	ProviderAzureDevOps     = "azure_devops"
+	ProviderGitea           = "gitea"
+	ProviderGogs            = "gogs"
+)
+
+// providerSpec is the data every registered provider shares: what ProviderConfig keys it
+// requires and how to build the URL of a freshly created repo. Every provider here uses the
+// same username/password credential shape (defaultRepoCredential) - one that doesn't has to
+// override BuildRepoCredential on tableGitProvider directly instead of adding a field here.
+type providerSpec struct {
+	name           string
+	requiredFields []string
+	// repoUrl builds the clone URL for a repo named "name" under cfg's ProviderConfig/Host.
+	repoUrl func(cfg *GitOpsConfigDto, name string) string
+}
+
+var providerSpecs = []providerSpec{
+	{
+		name:           ProviderGitHub,
+		requiredFields: []string{"org"},
+		repoUrl: func(cfg *GitOpsConfigDto, name string) string {
+			return fmt.Sprintf("https://github.com/%s/%s.git", providerConfigString(cfg, "org"), name)
+		},
+	},
+	{
+		name:           ProviderGitLab,
+		requiredFields: []string{"group"},
+		repoUrl: func(cfg *GitOpsConfigDto, name string) string {
+			return fmt.Sprintf("https://gitlab.com/%s/%s.git", providerConfigString(cfg, "group"), name)
+		},
+	},
+	{
+		name:           ProviderBitbucketCloud,
+		requiredFields: []string{"workspace"},
+		repoUrl: func(cfg *GitOpsConfigDto, name string) string {
+			return fmt.Sprintf("https://bitbucket.org/%s/%s.git", providerConfigString(cfg, "workspace"), name)
+		},
+	},
+	{
+		name:           ProviderBitbucketServer,
+		requiredFields: []string{"project"},
+		repoUrl: func(cfg *GitOpsConfigDto, name string) string {
+			return fmt.Sprintf("%s/scm/%s/%s.git", cfg.Host, providerConfigString(cfg, "project"), name)
+		},
+	},
+	{
+		name:           ProviderAzureDevOps,
+		requiredFields: []string{"organization", "project"},
+		repoUrl: func(cfg *GitOpsConfigDto, name string) string {
+			return fmt.Sprintf("https://dev.azure.com/%s/%s/_git/%s", providerConfigString(cfg, "organization"), providerConfigString(cfg, "project"), name)
+		},
+	},
+	{
+		name:           ProviderGitea,
+		requiredFields: []string{"org"},
+		repoUrl: func(cfg *GitOpsConfigDto, name string) string {
+			return fmt.Sprintf("%s/%s/%s.git", cfg.Host, providerConfigString(cfg, "org"), name)
+		},
+	},
+	{
+		name:           ProviderGogs,
+		requiredFields: []string{"org"},
+		repoUrl: func(cfg *GitOpsConfigDto, name string) string {
+			return fmt.Sprintf("%s/%s/%s.git", cfg.Host, providerConfigString(cfg, "org"), name)
+		},
+	},
+}
+
+// tableGitProvider implements GitProvider for every entry in providerSpecs - the providers
+// differ only in which ProviderConfig keys they require and how they shape a repo URL, so one
+// implementation driven by providerSpec replaces what used to be a struct per provider.
+type tableGitProvider struct {
+	spec providerSpec
+}
+
+func (p *tableGitProvider) Name() string { return p.spec.name }
+
+func (p *tableGitProvider) Schema() *GitProviderSchema {
+	return &GitProviderSchema{Provider: p.spec.name, RequiredFields: p.spec.requiredFields}
+}
+
+func (p *tableGitProvider) Validate(cfg *GitOpsConfigDto) error {
+	var missing []string
+	for _, field := range p.spec.requiredFields {
+		if providerConfigString(cfg, field) == "" {
+			missing = append(missing, "providerConfig."+field)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%s %s required for %s", strings.Join(missing, " and "), pluralIs(len(missing)), p.spec.name)
+	}
+	return nil
+}
+
+func (p *tableGitProvider) BuildRepoCredential(cfg *GitOpsConfigDto, secretName string) *RepositoryCredentialsDto {
+	return defaultRepoCredential(cfg, secretName)
+}
+
+func (p *tableGitProvider) CreateRepo(ctx context.Context, cfg *GitOpsConfigDto, name, description string) (string, error) {
+	return p.spec.repoUrl(cfg, name), nil
+}
+
+func pluralIs(n int) string {
+	if n == 1 {
+		return "is"
+	}
+	return "are"
+}
+
+type gitProviderRegistry struct {
+	providers map[string]GitProvider
+}
+
+var defaultGitProviderRegistry = newGitProviderRegistry()
+
+func newGitProviderRegistry() *gitProviderRegistry {
+	r := &gitProviderRegistry{providers: map[string]GitProvider{}}
+	for _, spec := range providerSpecs {
+		r.register(&tableGitProvider{spec: spec})
+	}
+	return r
+}
+
+func (r *gitProviderRegistry) register(p GitProvider) {
+	r.providers[p.Name()] = p
+}
+
+func (r *gitProviderRegistry) get(name string) (GitProvider, error) {
+	p, ok := r.providers[name]
+	if !ok {
+		return nil, fmt.Errorf("no git provider registered for %q", name)
+	}
+	return p, nil
+}
+
+func (r *gitProviderRegistry) schemas() []*GitProviderSchema {
+	schemas := make([]*GitProviderSchema, 0, len(r.providers))
+	for _, p := range r.providers {
+		if s, ok := p.(interface{ Schema() *GitProviderSchema }); ok {
+			schemas = append(schemas, s.Schema())
+		}
+	}
+	return schemas
+}
+
+// GetGitProviderSchemas backs the GET /orchestrator/gitops/providers endpoint: the schema
+// (required fields) for every registered provider, so the UI can render provider-specific
+// forms. No validation regexes are defined, so FieldPatterns is always empty.
+func (impl *GitOpsConfigServiceImpl) GetGitProviderSchemas() []*GitProviderSchema {
+	return defaultGitProviderRegistry.schemas()
+}
+
+func providerConfigString(cfg *GitOpsConfigDto, key string) string {
+	if cfg.ProviderConfig == nil {
+		return ""
+	}
+	return cfg.ProviderConfig[key]
+}
+
+// defaultRepoCredential is the shared shape used by every provider today - a username/password
+// secret reference keyed by Host. Providers with a genuinely different credential shape (e.g.
+// SSH) can override BuildRepoCredential instead of calling this helper.
+func defaultRepoCredential(cfg *GitOpsConfigDto, secretName string) *RepositoryCredentialsDto {
+	return &RepositoryCredentialsDto{
+		Url:            cfg.Host,
+		UsernameSecret: &KeyDto{Name: secretName, Key: "username"},
+		PasswordSecret: &KeyDto{Name: secretName, Key: "password"},
+	}
+}
@@ -0,0 +1,95 @@
+/*
+ * Copyright (c) 2020 Devtron Labs
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package gitops
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGitProviderRegistry_Get(t *testing.T) {
+	for _, spec := range providerSpecs {
+		provider, err := defaultGitProviderRegistry.get(spec.name)
+		assert.NoError(t, err)
+		assert.Equal(t, spec.name, provider.Name())
+	}
+	_, err := defaultGitProviderRegistry.get("no-such-provider")
+	assert.Error(t, err)
+}
+
+func TestTableGitProvider_Validate(t *testing.T) {
+	cases := []struct {
+		name      string
+		provider  string
+		cfg       map[string]string
+		wantError bool
+	}{
+		{name: "github missing org", provider: ProviderGitHub, cfg: map[string]string{}, wantError: true},
+		{name: "github with org", provider: ProviderGitHub, cfg: map[string]string{"org": "devtron-labs"}},
+		{name: "azure missing both", provider: ProviderAzureDevOps, cfg: map[string]string{}, wantError: true},
+		{name: "azure missing project", provider: ProviderAzureDevOps, cfg: map[string]string{"organization": "acme"}, wantError: true},
+		{name: "azure with both", provider: ProviderAzureDevOps, cfg: map[string]string{"organization": "acme", "project": "platform"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			provider, err := defaultGitProviderRegistry.get(tc.provider)
+			assert.NoError(t, err)
+			err = provider.Validate(&GitOpsConfigDto{ProviderConfig: tc.cfg})
+			if tc.wantError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestTableGitProvider_BuildRepoCredential(t *testing.T) {
+	provider, err := defaultGitProviderRegistry.get(ProviderGitHub)
+	assert.NoError(t, err)
+	cred := provider.BuildRepoCredential(&GitOpsConfigDto{Host: "https://github.com"}, "devtron-gitops-secret-myconfig")
+	assert.Equal(t, "https://github.com", cred.Url)
+	assert.Equal(t, "devtron-gitops-secret-myconfig", cred.UsernameSecret.Name)
+	assert.Equal(t, "username", cred.UsernameSecret.Key)
+	assert.Equal(t, "password", cred.PasswordSecret.Key)
+}
+
+func TestTableGitProvider_CreateRepo(t *testing.T) {
+	cases := []struct {
+		name     string
+		provider string
+		cfg      map[string]string
+		host     string
+		wantUrl  string
+	}{
+		{name: "github", provider: ProviderGitHub, cfg: map[string]string{"org": "devtron-labs"}, wantUrl: "https://github.com/devtron-labs/my-repo.git"},
+		{name: "azure devops", provider: ProviderAzureDevOps, cfg: map[string]string{"organization": "acme", "project": "platform"}, wantUrl: "https://dev.azure.com/acme/platform/_git/my-repo"},
+		{name: "bitbucket server", provider: ProviderBitbucketServer, cfg: map[string]string{"project": "PLAT"}, host: "https://bb.internal", wantUrl: "https://bb.internal/scm/PLAT/my-repo.git"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			provider, err := defaultGitProviderRegistry.get(tc.provider)
+			assert.NoError(t, err)
+			url, err := provider.CreateRepo(context.Background(), &GitOpsConfigDto{Host: tc.host, ProviderConfig: tc.cfg}, "my-repo", "test repo")
+			assert.NoError(t, err)
+			assert.Equal(t, tc.wantUrl, url)
+		})
+	}
+}
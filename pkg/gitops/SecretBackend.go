@@ -0,0 +1,303 @@
+/*
+ * Copyright (c) 2020 Devtron Labs
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package gitops
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	vaultapi "github.com/hashicorp/vault/api"
+	vaultauth "github.com/hashicorp/vault/api/auth/approle"
+	vaultk8sauth "github.com/hashicorp/vault/api/auth/kubernetes"
+
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+
+	gcpsecretmanager "cloud.google.com/go/secretmanager/apiv1"
+	gcpsecretmanagerpb "cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+)
+
+// SecretBackendType names where a GitOpsConfig's credential actually lives. The zero value
+// (empty string) is treated as SecretBackendK8s for backwards compatibility with configs
+// created before this field existed.
+type SecretBackendType string
+
+const (
+	SecretBackendK8s   SecretBackendType = "k8s"
+	SecretBackendVault SecretBackendType = "vault"
+	SecretBackendAwsSm SecretBackendType = "awssm"
+	SecretBackendGcpSm SecretBackendType = "gcpsm"
+)
+
+// SecretBackend resolves a SecretRef into the plaintext credential that should be
+// materialized into the devtron-gitops-secret K8s Secret. Implementations are looked up
+// by SecretBackendType via NewSecretBackend; ArgoCD only ever talks to the K8s Secret that
+// the resolved value gets written into, so it stays unaware of which backend is in use.
+type SecretBackend interface {
+	Type() SecretBackendType
+	// Resolve fetches the current plaintext value for ref, e.g. a Vault KV path or an
+	// AWS/GCP secret name.
+	Resolve(ref string) (string, error)
+}
+
+func NewSecretBackend(backendType SecretBackendType, k8sUtil interface{}) (SecretBackend, error) {
+	switch backendType {
+	case "", SecretBackendK8s:
+		return &k8sSecretBackend{}, nil
+	case SecretBackendVault:
+		return newVaultSecretBackend(), nil
+	case SecretBackendAwsSm:
+		return newAwsSmSecretBackend(), nil
+	case SecretBackendGcpSm:
+		return newGcpSmSecretBackend(), nil
+	default:
+		return nil, fmt.Errorf("unknown secret backend %q", backendType)
+	}
+}
+
+// k8sSecretBackend is a no-op backend: the plaintext token already lives directly on the
+// GitOpsConfigDto, so there is no external reference to resolve.
+type k8sSecretBackend struct{}
+
+func (b *k8sSecretBackend) Type() SecretBackendType { return SecretBackendK8s }
+func (b *k8sSecretBackend) Resolve(ref string) (string, error) {
+	return ref, nil
+}
+
+// vaultSecretBackend resolves a HashiCorp Vault KV v2 path using either AppRole or
+// Kubernetes auth, whichever VAULT_AUTH_METHOD selects (read each time NewSecretBackend builds
+// one; "kubernetes" when unset). ref is the secret's path
+// under KvMount, e.g. "gitops/github-prod".
+type vaultSecretBackend struct {
+	Addr       string
+	KvMount    string
+	AuthMethod string // "approle" or "kubernetes"
+	Role       string
+}
+
+func newVaultSecretBackend() *vaultSecretBackend {
+	kvMount := os.Getenv("VAULT_KV_MOUNT")
+	if kvMount == "" {
+		kvMount = "secret"
+	}
+	authMethod := os.Getenv("VAULT_AUTH_METHOD")
+	if authMethod == "" {
+		authMethod = "kubernetes"
+	}
+	return &vaultSecretBackend{
+		Addr:       os.Getenv("VAULT_ADDR"),
+		KvMount:    kvMount,
+		AuthMethod: authMethod,
+		Role:       os.Getenv("VAULT_ROLE"),
+	}
+}
+
+func (b *vaultSecretBackend) Type() SecretBackendType { return SecretBackendVault }
+
+func (b *vaultSecretBackend) Resolve(ref string) (string, error) {
+	cfg := vaultapi.DefaultConfig()
+	if b.Addr != "" {
+		cfg.Address = b.Addr
+	}
+	client, err := vaultapi.NewClient(cfg)
+	if err != nil {
+		return "", fmt.Errorf("error creating vault client: %w", err)
+	}
+	ctx := context.Background()
+	if err := b.login(ctx, client); err != nil {
+		return "", fmt.Errorf("error authenticating to vault: %w", err)
+	}
+	secret, err := client.KVv2(b.KvMount).Get(ctx, ref)
+	if err != nil {
+		return "", fmt.Errorf("error reading vault secret %q: %w", ref, err)
+	}
+	value, ok := secret.Data["value"].(string)
+	if !ok {
+		return "", fmt.Errorf("vault secret %q has no string \"value\" field", ref)
+	}
+	return value, nil
+}
+
+// login authenticates client using AppRole or Kubernetes auth, mirroring the two auth methods
+// devtron itself already supports for connecting to Vault elsewhere in the platform.
+func (b *vaultSecretBackend) login(ctx context.Context, client *vaultapi.Client) error {
+	switch b.AuthMethod {
+	case "approle":
+		auth, err := vaultauth.NewAppRoleAuth(b.Role, &vaultauth.SecretID{FromEnv: "VAULT_SECRET_ID"})
+		if err != nil {
+			return err
+		}
+		secret, err := client.Auth().Login(ctx, auth)
+		if err != nil {
+			return err
+		}
+		client.SetToken(secret.Auth.ClientToken)
+		return nil
+	case "kubernetes":
+		auth, err := vaultk8sauth.NewKubernetesAuth(b.Role)
+		if err != nil {
+			return err
+		}
+		secret, err := client.Auth().Login(ctx, auth)
+		if err != nil {
+			return err
+		}
+		client.SetToken(secret.Auth.ClientToken)
+		return nil
+	default:
+		return fmt.Errorf("unknown VAULT_AUTH_METHOD %q", b.AuthMethod)
+	}
+}
+
+// awsSmSecretBackend resolves a secret name/ARN from AWS Secrets Manager using the default
+// credential chain (IRSA, instance profile, env vars, ...). ref is the SecretId AWS expects -
+// a name or a full ARN.
+type awsSmSecretBackend struct {
+	Region string
+}
+
+func newAwsSmSecretBackend() *awsSmSecretBackend {
+	return &awsSmSecretBackend{Region: os.Getenv("AWS_REGION")}
+}
+
+func (b *awsSmSecretBackend) Type() SecretBackendType { return SecretBackendAwsSm }
+
+func (b *awsSmSecretBackend) Resolve(ref string) (string, error) {
+	ctx := context.Background()
+	opts := []func(*awsconfig.LoadOptions) error{}
+	if b.Region != "" {
+		opts = append(opts, awsconfig.WithRegion(b.Region))
+	}
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return "", fmt.Errorf("error loading aws config: %w", err)
+	}
+	client := secretsmanager.NewFromConfig(cfg)
+	out, err := client.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{SecretId: &ref})
+	if err != nil {
+		return "", fmt.Errorf("error reading aws secret %q: %w", ref, err)
+	}
+	if out.SecretString == nil {
+		return "", fmt.Errorf("aws secret %q has no SecretString (binary secrets are not supported)", ref)
+	}
+	return *out.SecretString, nil
+}
+
+// gcpSmSecretBackend resolves a secret from GCP Secret Manager. ref may be a bare secret name
+// (resolved against ProjectId at the "latest" version) or a fully qualified resource name
+// ("projects/.../secrets/.../versions/...").
+type gcpSmSecretBackend struct {
+	ProjectId string
+}
+
+func newGcpSmSecretBackend() *gcpSmSecretBackend {
+	return &gcpSmSecretBackend{ProjectId: os.Getenv("GCP_PROJECT_ID")}
+}
+
+func (b *gcpSmSecretBackend) Type() SecretBackendType { return SecretBackendGcpSm }
+
+func (b *gcpSmSecretBackend) Resolve(ref string) (string, error) {
+	ctx := context.Background()
+	client, err := gcpsecretmanager.NewClient(ctx)
+	if err != nil {
+		return "", fmt.Errorf("error creating gcp secret manager client: %w", err)
+	}
+	defer client.Close()
+	name := ref
+	if !strings.HasPrefix(name, "projects/") {
+		if b.ProjectId == "" {
+			return "", fmt.Errorf("gcp secret manager ref %q is not fully qualified and GCP_PROJECT_ID is not set", ref)
+		}
+		name = fmt.Sprintf("projects/%s/secrets/%s/versions/latest", b.ProjectId, ref)
+	}
+	result, err := client.AccessSecretVersion(ctx, &gcpsecretmanagerpb.AccessSecretVersionRequest{Name: name})
+	if err != nil {
+		return "", fmt.Errorf("error reading gcp secret %q: %w", name, err)
+	}
+	return string(result.Payload.Data), nil
+}
+
+// resolveSecretValue returns the plaintext value to write into the devtron-gitops-secret
+// Secret, dispatching on AuthMode/SecretBackend: OAuth configs use the live access token,
+// externally-backed configs are resolved through their SecretBackend, and everything else
+// falls back to the plain Token field.
+func (impl *GitOpsConfigServiceImpl) resolveSecretValue(request *GitOpsConfigDto) (string, error) {
+	if request.SecretBackend != "" && SecretBackendType(request.SecretBackend) != SecretBackendK8s {
+		backend, err := NewSecretBackend(SecretBackendType(request.SecretBackend), impl.K8sUtil)
+		if err != nil {
+			return "", err
+		}
+		return backend.Resolve(request.SecretRef)
+	}
+	return impl.credentialForSecret(request), nil
+}
+
+// SecretSyncInterval controls how often SyncSecrets re-reads external backends for
+// configs that reference one, so that a rotation in Vault/AWS/GCP propagates without a
+// Devtron restart.
+const SecretSyncInterval = 2 * time.Minute
+
+// SyncSecrets re-resolves every GitOpsConfig backed by a non-k8s SecretBackend and, if the
+// resolved value changed since last sync, rewrites the devtron-gitops-secret K8s Secret that
+// ArgoCD actually reads from. It is started once, the same way StartOAuthTokenRefresher is.
+func (impl *GitOpsConfigServiceImpl) SyncSecrets() {
+	configs, err := impl.gitOpsRepository.GetAllExternalBackedGitOpsConfigs()
+	if err != nil {
+		impl.logger.Errorw("error fetching externally-backed gitops configs", "err", err)
+		return
+	}
+	for _, model := range configs {
+		backend, err := NewSecretBackend(SecretBackendType(model.SecretBackend), impl.K8sUtil)
+		if err != nil {
+			impl.logger.Errorw("error constructing secret backend", "configId", model.Id, "backend", model.SecretBackend, "err", err)
+			continue
+		}
+		resolved, err := backend.Resolve(model.SecretRef)
+		if err != nil {
+			impl.logger.Errorw("error resolving external secret", "configId", model.Id, "ref", model.SecretRef, "err", err)
+			continue
+		}
+		if resolved == model.LastSyncedSecretValue {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), GitOpsK8sTimeout)
+		err = impl.rewriteSecretWithToken(ctx, secretNameForName(model.Name), model.Username, resolved)
+		cancel()
+		if err != nil {
+			impl.logger.Errorw("error rewriting gitops secret after external rotation", "configId", model.Id, "err", err)
+			continue
+		}
+		model.LastSyncedSecretValue = resolved
+		if err := impl.gitOpsRepository.UpdateLastSyncedSecretValue(model.Id, resolved); err != nil {
+			impl.logger.Errorw("error persisting last synced secret value", "configId", model.Id, "err", err)
+		}
+	}
+}
+
+// StartSecretSync launches the ticker-driven SyncSecrets reconcile loop.
+func (impl *GitOpsConfigServiceImpl) StartSecretSync() {
+	ticker := time.NewTicker(SecretSyncInterval)
+	go func() {
+		for range ticker.C {
+			impl.SyncSecrets()
+		}
+	}()
+}
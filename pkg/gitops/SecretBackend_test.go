@@ -0,0 +1,79 @@
+/*
+ * Copyright (c) 2020 Devtron Labs
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package gitops
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestK8sSecretBackend_Resolve(t *testing.T) {
+	backend := &k8sSecretBackend{}
+	value, err := backend.Resolve("plain-value")
+	assert.NoError(t, err)
+	assert.Equal(t, "plain-value", value)
+}
+
+func TestNewSecretBackend(t *testing.T) {
+	cases := []struct {
+		name        string
+		backendType SecretBackendType
+		wantType    SecretBackendType
+		wantErr     bool
+	}{
+		{name: "empty defaults to k8s", backendType: "", wantType: SecretBackendK8s},
+		{name: "explicit k8s", backendType: SecretBackendK8s, wantType: SecretBackendK8s},
+		{name: "vault", backendType: SecretBackendVault, wantType: SecretBackendVault},
+		{name: "aws secrets manager", backendType: SecretBackendAwsSm, wantType: SecretBackendAwsSm},
+		{name: "gcp secret manager", backendType: SecretBackendGcpSm, wantType: SecretBackendGcpSm},
+		{name: "unknown", backendType: "eecretsbox", wantErr: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			backend, err := NewSecretBackend(tc.backendType, nil)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.wantType, backend.Type())
+		})
+	}
+}
+
+func TestResolveSecretValue_FallsBackToTokenWhenNoExternalBackend(t *testing.T) {
+	impl := &GitOpsConfigServiceImpl{}
+	value, err := impl.resolveSecretValue(&GitOpsConfigDto{Token: "plain-token"})
+	assert.NoError(t, err)
+	assert.Equal(t, "plain-token", value)
+}
+
+func TestResolveSecretValue_UsesAccessTokenForOAuthConfigs(t *testing.T) {
+	impl := &GitOpsConfigServiceImpl{}
+	value, err := impl.resolveSecretValue(&GitOpsConfigDto{AuthMode: AuthModeOAuth, AccessToken: "live-access-token", Token: "stale-token"})
+	assert.NoError(t, err)
+	assert.Equal(t, "live-access-token", value)
+}
+
+func TestResolveSecretValue_K8sBackendIsTreatedAsNoExternalResolve(t *testing.T) {
+	impl := &GitOpsConfigServiceImpl{}
+	value, err := impl.resolveSecretValue(&GitOpsConfigDto{SecretBackend: string(SecretBackendK8s), Token: "plain-token"})
+	assert.NoError(t, err)
+	assert.Equal(t, "plain-token", value)
+}